@@ -0,0 +1,239 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/sirupsen/logrus"
+    corev1 "k8s.io/api/core/v1"
+    apierrors "k8s.io/apimachinery/pkg/api/errors"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/client-go/informers"
+    "k8s.io/client-go/kubernetes"
+    "k8s.io/client-go/tools/cache"
+    "k8s.io/client-go/tools/clientcmd"
+    "k8s.io/client-go/util/workqueue"
+)
+
+// podRequestsAnnotation mimics the annotation Rancher's own controller stamps on Nodes so the UI
+// shows used pod counts: a JSON-encoded object nested inside a JSON string value.
+const podRequestsAnnotation = "management.cattle.io/pod-requests"
+
+// buildMainClientset constructs the clientset used to talk to the shared KWOK main cluster,
+// honoring Config.MainQPS/MainBurst so list/watch traffic from the informers doesn't get
+// throttled once cluster/pod counts scale into the thousands.
+func buildMainClientset(cfg *Config) (kubernetes.Interface, error) {
+    kubeconfig := filepath.Join(osUserHome(), ".kwok", "clusters", cfg.MainClusterName, "kubeconfig.yaml")
+    loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+    loadingRules.ExplicitPath = kubeconfig
+    clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+    restConfig, err := clientConfig.ClientConfig()
+    if err != nil {
+        return nil, fmt.Errorf("failed to build main cluster client config: %w", err)
+    }
+    restConfig.QPS = cfg.MainQPS
+    restConfig.Burst = cfg.MainBurst
+    if restConfig.QPS <= 0 {
+        restConfig.QPS = 50
+    }
+    if restConfig.Burst <= 0 {
+        restConfig.Burst = 100
+    }
+    return kubernetes.NewForConfig(restConfig)
+}
+
+// Start builds the main cluster clientset and shared informer factory, starts the Pod/Node
+// informers, and launches the pod-usage annotation worker. Other subsystems (proxy, mock server)
+// read a.mainClientset/a.mainInformerFactory once this has returned rather than building their
+// own clients.
+func (a *ScaleAgent) Start(ctx context.Context) error {
+    if a.diag == nil {
+        a.diag = newDiagnosticsState(time.Duration(a.config.AnnotatorStallSec) * time.Second)
+    }
+    if !a.config.MultiTenant {
+        return nil // only meaningful in shared main cluster mode
+    }
+
+    clientset, err := buildMainClientset(a.config)
+    if err != nil {
+        return fmt.Errorf("cluster controller: %w", err)
+    }
+    a.mainClientset = clientset
+    a.mainInformerFactory = informers.NewSharedInformerFactory(clientset, 0)
+    a.podCounts = make(map[string]int)
+    a.nodeQueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+
+    podInformer := a.mainInformerFactory.Core().V1().Pods().Informer()
+    podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+        AddFunc:    a.onPodAdded,
+        UpdateFunc: a.onPodUpdated,
+        DeleteFunc: a.onPodDeleted,
+    })
+
+    nodeInformer := a.mainInformerFactory.Core().V1().Nodes().Informer()
+    nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+        AddFunc: func(obj interface{}) {
+            if node, ok := obj.(*corev1.Node); ok {
+                a.nodeQueue.Add(node.Name)
+            }
+        },
+    })
+
+    a.mainInformerFactory.Start(ctx.Done())
+    a.mainInformerFactory.WaitForCacheSync(ctx.Done())
+
+    go a.runNodeAnnotationWorker(ctx)
+    return nil
+}
+
+// onPodAdded adjusts the new pod's node count and enqueues it for (re-)annotation. Adjusting the
+// running total from the event itself (rather than re-listing every pod in the cluster) keeps each
+// event O(1) instead of O(total pods), which matters once pod/node counts reach the thousands this
+// project targets.
+func (a *ScaleAgent) onPodAdded(obj interface{}) {
+    pod, ok := obj.(*corev1.Pod)
+    if !ok || pod.Spec.NodeName == "" {
+        return
+    }
+    if podCountsAsRunning(pod) {
+        a.adjustPodCount(pod.Spec.NodeName, 1)
+    }
+    a.nodeQueue.Add(pod.Spec.NodeName)
+}
+
+// onPodUpdated adjusts podCounts for whatever actually changed between oldObj and newObj: a phase
+// transition into/out of terminal, or a change of node (including an unscheduled pod first being
+// bound to one), rather than a full recount.
+func (a *ScaleAgent) onPodUpdated(oldObj, newObj interface{}) {
+    newPod, ok := newObj.(*corev1.Pod)
+    if !ok || newPod.Spec.NodeName == "" {
+        return
+    }
+    oldPod, hadOld := oldObj.(*corev1.Pod)
+
+    switch {
+    case hadOld && oldPod.Spec.NodeName != newPod.Spec.NodeName:
+        if oldPod.Spec.NodeName != "" && podCountsAsRunning(oldPod) {
+            a.adjustPodCount(oldPod.Spec.NodeName, -1)
+        }
+        if podCountsAsRunning(newPod) {
+            a.adjustPodCount(newPod.Spec.NodeName, 1)
+        }
+    case hadOld && podCountsAsRunning(oldPod) != podCountsAsRunning(newPod):
+        delta := -1
+        if podCountsAsRunning(newPod) {
+            delta = 1
+        }
+        a.adjustPodCount(newPod.Spec.NodeName, delta)
+    case !hadOld && podCountsAsRunning(newPod):
+        // Lost the old object (e.g. resync replaying Add as Update); treat it as new.
+        a.adjustPodCount(newPod.Spec.NodeName, 1)
+    }
+    a.nodeQueue.Add(newPod.Spec.NodeName)
+}
+
+// onPodDeleted handles both direct deletes and cache.DeletedFinalStateUnknown tombstones.
+func (a *ScaleAgent) onPodDeleted(obj interface{}) {
+    pod, ok := obj.(*corev1.Pod)
+    if !ok {
+        tomb, ok := obj.(cache.DeletedFinalStateUnknown)
+        if !ok {
+            return
+        }
+        pod, ok = tomb.Obj.(*corev1.Pod)
+        if !ok {
+            return
+        }
+    }
+    if pod.Spec.NodeName == "" {
+        return
+    }
+    if podCountsAsRunning(pod) {
+        a.adjustPodCount(pod.Spec.NodeName, -1)
+    }
+    a.nodeQueue.Add(pod.Spec.NodeName)
+}
+
+// podCountsAsRunning reports whether pod should be counted toward its node's pod-requests total:
+// terminal pods (Succeeded/Failed) no longer hold resources and are excluded, matching kubectl's
+// own "non-terminal" accounting.
+func podCountsAsRunning(pod *corev1.Pod) bool {
+    return pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
+
+// adjustPodCount applies delta to nodeName's running total, floored at zero so a missed/duplicate
+// event can't drive the count negative.
+func (a *ScaleAgent) adjustPodCount(nodeName string, delta int) {
+    a.podCountsMu.Lock()
+    a.podCounts[nodeName] += delta
+    if a.podCounts[nodeName] < 0 {
+        a.podCounts[nodeName] = 0
+    }
+    a.podCountsMu.Unlock()
+}
+
+// runNodeAnnotationWorker drains the rate-limited workqueue, coalescing repeated dirty signals
+// for the same node into a single patch call.
+func (a *ScaleAgent) runNodeAnnotationWorker(ctx context.Context) {
+    for a.processNextNode(ctx) {
+        if a.diag != nil {
+            a.diag.recordAnnotatorPass()
+        }
+    }
+}
+
+func (a *ScaleAgent) processNextNode(ctx context.Context) bool {
+    key, shutdown := a.nodeQueue.Get()
+    if shutdown {
+        return false
+    }
+    defer a.nodeQueue.Done(key)
+
+    nodeName := key.(string)
+    if err := a.annotateNode(ctx, nodeName); err != nil {
+        logrus.Debugf("cluster controller: node %s: %v", nodeName, err)
+        a.nodeQueue.AddRateLimited(key)
+        return true
+    }
+    a.nodeQueue.Forget(key)
+    return true
+}
+
+// buildPodRequestsPatch renders the merge patch body for the management.cattle.io/pod-requests
+// annotation. inner is already a JSON-escaped string (the literal backslash-quotes are intentional,
+// per the Rancher annotation format); interpolating it with %s keeps it that way. Using %q here
+// would run Go-string escaping over it a second time and double every backslash, corrupting the
+// annotation so Rancher's json.Unmarshal of the nested object fails.
+func buildPodRequestsPatch(count int) []byte {
+    inner := fmt.Sprintf(`{\"pods\":\"%d\"}`, count)
+    return []byte(fmt.Sprintf(`{"metadata":{"annotations":{"%s":"%s"}}}`, podRequestsAnnotation, inner))
+}
+
+// annotateNode applies the Rancher-compatible management.cattle.io/pod-requests annotation to a
+// single node via a typed merge patch.
+func (a *ScaleAgent) annotateNode(ctx context.Context, nodeName string) error {
+    a.podCountsMu.RLock()
+    count := a.podCounts[nodeName]
+    a.podCountsMu.RUnlock()
+
+    patch := buildPodRequestsPatch(count)
+
+    _, err := a.mainClientset.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+    if apierrors.IsNotFound(err) {
+        return nil // node was deleted between enqueue and patch
+    }
+    if err != nil {
+        return fmt.Errorf("patch node: %w", err)
+    }
+    logrus.Debugf("cluster controller: node %s annotated pods=%d", nodeName, count)
+    return nil
+}
+
+func osUserHome() string {
+    home, _ := os.UserHomeDir()
+    return home
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "os/exec"
+    "sync/atomic"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/promauto"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus metrics for this module's own state, distinct from whatever the simulated clusters
+// report. Registered against the default registry so promhttp.Handler() picks them up for free.
+var (
+    metricClusters = promauto.NewGauge(prometheus.GaugeOpts{
+        Name: "scale_cluster_agent_clusters",
+        Help: "Number of simulated clusters currently tracked by the agent.",
+    })
+    metricActiveConnections = promauto.NewGauge(prometheus.GaugeOpts{
+        Name: "scale_cluster_agent_active_connections",
+        Help: "Number of active remotedialer tunnel connections.",
+    })
+    metricClusterAgentSessions = promauto.NewGauge(prometheus.GaugeOpts{
+        Name: "scale_cluster_agent_cluster_agent_sessions",
+        Help: "Number of active cluster-agent remotedialer sessions.",
+    })
+    metricProxyUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+        Name: "scale_cluster_agent_proxy_up",
+        Help: "Whether the per-cluster kubectl proxy is up (1) or down (0).",
+    }, []string{"cluster_id"})
+    metricConnectAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+        Name: "scale_cluster_agent_connect_attempts_total",
+        Help: "Number of tunnel connect/backoff attempts, by cluster and kind.",
+    }, []string{"cluster_id", "kind"})
+    metricStateSaveLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+        Name:    "scale_cluster_agent_state_save_seconds",
+        Help:    "Latency of persisting agent state to disk.",
+        Buckets: prometheus.DefBuckets,
+    })
+    metricPodCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
+        Name: "scale_cluster_agent_node_pod_count",
+        Help: "Non-terminal pod count observed per node by the pod usage annotator.",
+    }, []string{"node"})
+)
+
+// diagnosticsState tracks the readiness/liveness signals that healthz/readyz report on, separate
+// from ScaleAgent's own mutex-guarded fields so the HTTP handlers never have to take connMutex.
+type diagnosticsState struct {
+    ready              int32 // atomic bool: LoadState succeeded and main cluster API reachable
+    lastAnnotatorRunNS int64 // atomic unix nanos of the last pod usage annotator pass
+    stallThreshold     time.Duration
+}
+
+func newDiagnosticsState(stallThreshold time.Duration) *diagnosticsState {
+    if stallThreshold <= 0 {
+        stallThreshold = 2 * time.Minute
+    }
+    return &diagnosticsState{stallThreshold: stallThreshold}
+}
+
+func (d *diagnosticsState) setReady(ready bool) {
+    v := int32(0)
+    if ready {
+        v = 1
+    }
+    atomic.StoreInt32(&d.ready, v)
+}
+
+func (d *diagnosticsState) isReady() bool {
+    return atomic.LoadInt32(&d.ready) == 1
+}
+
+func (d *diagnosticsState) recordAnnotatorPass() {
+    atomic.StoreInt64(&d.lastAnnotatorRunNS, time.Now().UnixNano())
+}
+
+// annotatorStalled reports whether the pod usage annotator hasn't completed a pass within
+// stallThreshold, once it has run at least once.
+func (d *diagnosticsState) annotatorStalled() bool {
+    last := atomic.LoadInt64(&d.lastAnnotatorRunNS)
+    if last == 0 {
+        return false // hasn't had a chance to run yet
+    }
+    return time.Since(time.Unix(0, last)) > d.stallThreshold
+}
+
+// installDiagnosticsMux mounts /healthz, /readyz and /metrics on mux (the same mux pprof is
+// already mounted on, or a dedicated Config.MetricsPort listener).
+func installDiagnosticsMux(mux *http.ServeMux, diag *diagnosticsState) {
+    mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+        if diag.annotatorStalled() {
+            http.Error(w, "pod usage annotator stalled", http.StatusServiceUnavailable)
+            return
+        }
+        w.WriteHeader(http.StatusOK)
+        _, _ = w.Write([]byte("ok"))
+    })
+    mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+        if !diag.isReady() {
+            http.Error(w, "not ready", http.StatusServiceUnavailable)
+            return
+        }
+        w.WriteHeader(http.StatusOK)
+        _, _ = w.Write([]byte("ok"))
+    })
+    mux.Handle("/metrics", promhttp.Handler())
+}
+
+// checkMainClusterReady does a cheap API discovery call against the main KWOK cluster to confirm
+// it's reachable; used to flip readiness true only once LoadState and this have both succeeded.
+func checkMainClusterReady(ctx context.Context, a *ScaleAgent) error {
+    if a.mainClientset == nil {
+        return nil // non-multi-tenant mode: nothing to check
+    }
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+    _, err := a.mainClientset.Discovery().ServerVersion()
+    _ = ctx
+    return err
+}
+
+// UpdateReadiness re-evaluates and stores whether the agent is ready: LoadState must already have
+// succeeded (the caller only invokes this after a successful load) and, in multi-tenant mode, the
+// main KWOK cluster's API must answer a discovery call.
+func (a *ScaleAgent) UpdateReadiness(ctx context.Context) {
+    if a.diag == nil {
+        return
+    }
+    a.diag.setReady(checkMainClusterReady(ctx, a) == nil)
+}
+
+// startMetricsRefresher periodically recomputes the agent-level gauges on interval, stopping when
+// ctx is done. Mirrors the existing MemLogIntervalSec-driven logger's lifecycle.
+func startMetricsRefresher(ctx context.Context, a *ScaleAgent, interval time.Duration) {
+    if interval <= 0 {
+        interval = 15 * time.Second
+    }
+    go func() {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+                a.refreshClusterMetrics()
+            }
+        }
+    }()
+}
+
+// refreshClusterMetrics updates the agent-level gauges this module tracks. Called periodically
+// alongside the existing MemLogIntervalSec logger.
+func (a *ScaleAgent) refreshClusterMetrics() {
+    a.connMutex.RLock()
+    metricClusters.Set(float64(len(a.clusters)))
+    metricActiveConnections.Set(float64(len(a.activeConnections)))
+    metricClusterAgentSessions.Set(float64(len(a.clusterAgentSessions)))
+    // Reset first so a cluster whose proxy entry was torn down (removed from proxyCmds) doesn't
+    // leave a stale "up" series behind; only clusters still present get a value this pass.
+    metricProxyUp.Reset()
+    for clusterID, cmd := range a.proxyCmds {
+        metricProxyUp.WithLabelValues(clusterID).Set(boolToFloat(proxyCmdRunning(cmd)))
+    }
+    a.connMutex.RUnlock()
+
+    a.podCountsMu.RLock()
+    for node, count := range a.podCounts {
+        metricPodCount.WithLabelValues(node).Set(float64(count))
+    }
+    a.podCountsMu.RUnlock()
+}
+
+// proxyCmdRunning reports whether cmd's process is still alive: it's been started but hasn't been
+// reaped (ProcessState is only populated once something has called Wait on it).
+func proxyCmdRunning(cmd *exec.Cmd) bool {
+    return cmd != nil && cmd.Process != nil && cmd.ProcessState == nil
+}
+
+func boolToFloat(b bool) float64 {
+    if b {
+        return 1
+    }
+    return 0
+}
+
+// recordConnectAttempt increments the connect-attempts counter for clusterID and kind ("agent" or
+// "ca", matching lastConnectAttempt/lastCAConnectAttempt) and stamps the corresponding attempt
+// timestamp. The tunnel dial loop that owns those retries is outside this fragment; this is the
+// single place it should call into once it exists, so the counter and timestamps stay consistent.
+func (a *ScaleAgent) recordConnectAttempt(clusterID, kind string) {
+    metricConnectAttempts.WithLabelValues(clusterID, kind).Inc()
+
+    a.connMutex.Lock()
+    defer a.connMutex.Unlock()
+    now := time.Now()
+    switch kind {
+    case "ca":
+        if a.lastCAConnectAttempt == nil {
+            a.lastCAConnectAttempt = make(map[string]time.Time)
+        }
+        a.lastCAConnectAttempt[clusterID] = now
+    default:
+        if a.lastConnectAttempt == nil {
+            a.lastConnectAttempt = make(map[string]time.Time)
+        }
+        a.lastConnectAttempt[clusterID] = now
+    }
+}
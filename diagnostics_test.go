@@ -0,0 +1,58 @@
+package main
+
+import (
+    "os/exec"
+    "testing"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestProxyCmdRunning guards against the tautological "is it a key in the map" liveness check:
+// proxyCmdRunning must reflect whether the process has actually exited, not just whether an entry
+// still exists in proxyCmds.
+func TestProxyCmdRunning(t *testing.T) {
+    if proxyCmdRunning(nil) {
+        t.Fatal("nil cmd should not be reported as running")
+    }
+
+    cmd := exec.Command("sleep", "5")
+    if err := cmd.Start(); err != nil {
+        t.Skipf("could not start test process: %v", err)
+    }
+    if !proxyCmdRunning(cmd) {
+        t.Fatal("just-started process should be reported as running")
+    }
+
+    if err := cmd.Process.Kill(); err != nil {
+        t.Fatalf("kill test process: %v", err)
+    }
+    _ = cmd.Wait()
+    if proxyCmdRunning(cmd) {
+        t.Fatal("reaped process should not be reported as running")
+    }
+}
+
+// TestRecordConnectAttempt guards against metricConnectAttempts staying dead: recording an attempt
+// must increment the counter for the right cluster/kind and stamp the matching timestamp map.
+func TestRecordConnectAttempt(t *testing.T) {
+    a := &ScaleAgent{}
+
+    before := time.Now()
+    a.recordConnectAttempt("cluster-1", "agent")
+    a.recordConnectAttempt("cluster-1", "ca")
+
+    if got := testutil.ToFloat64(metricConnectAttempts.WithLabelValues("cluster-1", "agent")); got != 1 {
+        t.Fatalf("expected agent connect attempts = 1, got %v", got)
+    }
+    if got := testutil.ToFloat64(metricConnectAttempts.WithLabelValues("cluster-1", "ca")); got != 1 {
+        t.Fatalf("expected ca connect attempts = 1, got %v", got)
+    }
+
+    if a.lastConnectAttempt["cluster-1"].Before(before) {
+        t.Fatal("lastConnectAttempt not stamped")
+    }
+    if a.lastCAConnectAttempt["cluster-1"].Before(before) {
+        t.Fatal("lastCAConnectAttempt not stamped")
+    }
+}
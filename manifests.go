@@ -0,0 +1,721 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "sigs.k8s.io/yaml"
+)
+
+// manifestDoc is the minimal TypeMeta+ObjectMeta envelope used to dispatch a YAML document to the
+// right *Info struct before doing a full per-kind unmarshal.
+type manifestDoc struct {
+    Kind     string `json:"kind"`
+    Metadata struct {
+        Name      string `json:"name"`
+        Namespace string `json:"namespace"`
+    } `json:"metadata"`
+}
+
+// handleApplyCluster implements POST/DELETE /clusters/{id}/apply: it accepts a multi-document
+// Kubernetes YAML body (analogous in spirit to `podman play kube`) and translates each document
+// into the corresponding *Info struct on the target ClusterInfo. In multi-tenant mode the same
+// objects are also created in the shared KWOK cluster, namespaced per virtual cluster, so
+// KWOK/Rancher see them.
+func (a *ScaleAgent) handleApplyCluster(w http.ResponseWriter, r *http.Request, clusterID string) {
+    dryRun := r.URL.Query().Get("dry-run") == "true" || r.URL.Query().Get("dry-run") == "1"
+
+    defer r.Body.Close()
+    body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 16<<20))
+    if err != nil {
+        http.Error(w, fmt.Sprintf("failed to read body: %v", err), http.StatusBadRequest)
+        return
+    }
+
+    switch r.Method {
+    case http.MethodPost:
+        result, err := a.ApplyManifest(clusterID, body, dryRun)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        writeJSON(w, http.StatusOK, result)
+    case http.MethodDelete:
+        result, err := a.DeleteManifest(clusterID, body, dryRun)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        writeJSON(w, http.StatusOK, result)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+// handleExportCluster implements GET /clusters/{id}/export: it emits the current ClusterInfo as
+// multi-document YAML, the inverse of handleApplyCluster.
+func (a *ScaleAgent) handleExportCluster(w http.ResponseWriter, r *http.Request, clusterID string) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    data, err := a.ExportCluster(clusterID)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    w.Header().Set("Content-Type", "application/x-yaml")
+    _, _ = w.Write(data)
+}
+
+// ApplyResult summarizes what an apply/delete call did, one entry per parsed document.
+type ApplyResult struct {
+    DryRun  bool     `json:"dryRun"`
+    Applied []string `json:"applied"` // "<kind>/<namespace>/<name>" per document
+}
+
+// parseManifestDocs decodes just the TypeMeta+ObjectMeta envelope of each document, up front, so
+// a malformed document is rejected before anything touches agent state.
+func parseManifestDocs(docs [][]byte) ([]manifestDoc, error) {
+    metas := make([]manifestDoc, len(docs))
+    for i, doc := range docs {
+        if err := yaml.Unmarshal(doc, &metas[i]); err != nil {
+            return nil, fmt.Errorf("invalid manifest document: %w", err)
+        }
+    }
+    return metas, nil
+}
+
+// clusterSnapshot returns a deep copy of the named cluster's current state, for dry-run use: it
+// must never be handed back to UpdateState, since committing it would silently overwrite whatever
+// concurrent writers did in between the snapshot and the (never-taken) commit.
+func (a *ScaleAgent) clusterSnapshot(clusterID string) (*ClusterInfo, error) {
+    a.stateMu.Lock()
+    cur, err := a.snapshotStateLocked()
+    a.stateMu.Unlock()
+    if err != nil {
+        return nil, err
+    }
+    cluster, ok := cur.Clusters[clusterID]
+    if !ok {
+        return nil, fmt.Errorf("unknown cluster %q", clusterID)
+    }
+    return cluster, nil
+}
+
+// applyDocsToCluster applies each non-blank document in docs/metas to cluster, returning the
+// "<kind>/<namespace>/<name>" summary for ApplyManifest's result. Pulled out so both the real
+// UpdateState-backed path and the dry-run snapshot path run the identical mutation logic.
+func applyDocsToCluster(cluster *ClusterInfo, docs [][]byte, metas []manifestDoc) ([]string, error) {
+    var applied []string
+    for i, meta := range metas {
+        if meta.Kind == "" {
+            continue // blank document between "---" separators
+        }
+        // Normalize the namespace the same way applyManifestDoc's per-kind decoders do, so the
+        // key recorded here (and used against the main cluster) matches what's actually stored.
+        namespace := defaultNamespace(meta.Metadata.Namespace)
+        if err := applyManifestDoc(cluster, meta.Kind, docs[i]); err != nil {
+            return nil, fmt.Errorf("apply %s/%s: %w", meta.Kind, meta.Metadata.Name, err)
+        }
+        applied = append(applied, fmt.Sprintf("%s/%s/%s", meta.Kind, namespace, meta.Metadata.Name))
+    }
+    return applied, nil
+}
+
+// ApplyManifest parses a multi-document Kubernetes YAML body and merges each object into the
+// target ClusterInfo. The merge runs as an UpdateState mutation: applyDocsToCluster edits a deep
+// copy of the cluster, and a failure partway through (a bad document later in the body) discards
+// that copy entirely instead of leaving earlier documents applied in memory but never persisted.
+// When dryRun is false and the agent is running in multi-tenant mode, the same objects are also
+// created in the shared KWOK cluster under the virtual cluster's namespace, after the local commit
+// succeeds.
+func (a *ScaleAgent) ApplyManifest(clusterID string, body []byte, dryRun bool) (*ApplyResult, error) {
+    docs := splitYAMLDocs(body)
+    metas, err := parseManifestDocs(docs)
+    if err != nil {
+        return nil, err
+    }
+
+    if dryRun {
+        cluster, err := a.clusterSnapshot(clusterID)
+        if err != nil {
+            return nil, err
+        }
+        applied, err := applyDocsToCluster(cluster, docs, metas)
+        if err != nil {
+            return nil, err
+        }
+        return &ApplyResult{DryRun: true, Applied: applied}, nil
+    }
+
+    result := &ApplyResult{DryRun: false}
+    if err := a.UpdateState(func(cur *State) (*State, error) {
+        cluster, ok := cur.Clusters[clusterID]
+        if !ok {
+            return nil, fmt.Errorf("unknown cluster %q", clusterID)
+        }
+        applied, err := applyDocsToCluster(cluster, docs, metas)
+        if err != nil {
+            return nil, err
+        }
+        result.Applied = applied
+        return cur, nil
+    }); err != nil {
+        return nil, err
+    }
+
+    if a.config.MultiTenant {
+        for i, meta := range metas {
+            if meta.Kind == "" {
+                continue
+            }
+            if err := a.applyToMainCluster(clusterID, meta.Kind, docs[i]); err != nil {
+                return nil, fmt.Errorf("apply %s/%s to main cluster: %w", meta.Kind, meta.Metadata.Name, err)
+            }
+        }
+    }
+    return result, nil
+}
+
+// deleteDocsFromCluster removes each non-blank document in metas from cluster, returning the
+// "<kind>/<namespace>/<name>" summary for DeleteManifest's result. Shared between the real
+// UpdateState-backed path and the dry-run snapshot path.
+func deleteDocsFromCluster(cluster *ClusterInfo, metas []manifestDoc) []string {
+    var applied []string
+    for _, meta := range metas {
+        if meta.Kind == "" {
+            continue
+        }
+        // Must match the namespace applyManifestDoc actually stored the object under (empty ->
+        // "default"), or a manifest applied with no namespace: field can never be deleted again.
+        namespace := defaultNamespace(meta.Metadata.Namespace)
+        deleteManifestDoc(cluster, meta.Kind, namespace, meta.Metadata.Name)
+        applied = append(applied, fmt.Sprintf("%s/%s/%s", meta.Kind, namespace, meta.Metadata.Name))
+    }
+    return applied
+}
+
+// DeleteManifest removes the objects named in the given multi-document YAML body from the target
+// ClusterInfo (and, outside dry-run in multi-tenant mode, from the shared KWOK cluster). Like
+// ApplyManifest, the removal runs as a single UpdateState mutation so a partial failure can't
+// leave some objects removed from memory without ever being persisted.
+func (a *ScaleAgent) DeleteManifest(clusterID string, body []byte, dryRun bool) (*ApplyResult, error) {
+    docs := splitYAMLDocs(body)
+    metas, err := parseManifestDocs(docs)
+    if err != nil {
+        return nil, err
+    }
+
+    if dryRun {
+        cluster, err := a.clusterSnapshot(clusterID)
+        if err != nil {
+            return nil, err
+        }
+        return &ApplyResult{DryRun: true, Applied: deleteDocsFromCluster(cluster, metas)}, nil
+    }
+
+    result := &ApplyResult{DryRun: false}
+    if err := a.UpdateState(func(cur *State) (*State, error) {
+        cluster, ok := cur.Clusters[clusterID]
+        if !ok {
+            return nil, fmt.Errorf("unknown cluster %q", clusterID)
+        }
+        result.Applied = deleteDocsFromCluster(cluster, metas)
+        return cur, nil
+    }); err != nil {
+        return nil, err
+    }
+
+    if a.config.MultiTenant {
+        for _, meta := range metas {
+            if meta.Kind == "" {
+                continue
+            }
+            namespace := defaultNamespace(meta.Metadata.Namespace)
+            if err := a.deleteFromMainCluster(clusterID, meta.Kind, namespace, meta.Metadata.Name); err != nil {
+                return nil, fmt.Errorf("delete %s/%s from main cluster: %w", meta.Kind, meta.Metadata.Name, err)
+            }
+        }
+    }
+    return result, nil
+}
+
+// ExportCluster marshals the current ClusterInfo back out as multi-document YAML.
+func (a *ScaleAgent) ExportCluster(clusterID string) ([]byte, error) {
+    a.connMutex.RLock()
+    cluster, ok := a.clusters[clusterID]
+    a.connMutex.RUnlock()
+    if !ok {
+        return nil, fmt.Errorf("unknown cluster %q", clusterID)
+    }
+
+    var out []byte
+    for i, obj := range exportableObjects(cluster) {
+        if i > 0 {
+            out = append(out, []byte("---\n")...)
+        }
+        doc, err := yaml.Marshal(obj)
+        if err != nil {
+            return nil, fmt.Errorf("marshal export document: %w", err)
+        }
+        out = append(out, doc...)
+    }
+    return out, nil
+}
+
+// registerManifestRoutes wires the apply/export endpoints onto mux. Paths are parsed by hand
+// (/clusters/{id}/apply, /clusters/{id}/export) to match the rest of this package's lack of a
+// router dependency.
+func registerManifestRoutes(mux *http.ServeMux, a *ScaleAgent) {
+    mux.HandleFunc("/clusters/", func(w http.ResponseWriter, r *http.Request) {
+        parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+        if parts[0] != "clusters" {
+            http.NotFound(w, r)
+            return
+        }
+        switch len(parts) {
+        case 3:
+            clusterID, action := parts[1], parts[2]
+            switch action {
+            case "apply":
+                a.handleApplyCluster(w, r, clusterID)
+            case "export":
+                a.handleExportCluster(w, r, clusterID)
+            default:
+                http.NotFound(w, r)
+            }
+        case 6:
+            // /clusters/{id}/pods/{ns}/{name}/log
+            clusterID, resource, namespace, name, sub := parts[1], parts[2], parts[3], parts[4], parts[5]
+            if resource != "pods" || sub != "log" {
+                http.NotFound(w, r)
+                return
+            }
+            a.handlePodLogs(w, r, clusterID, namespace, name)
+        default:
+            http.NotFound(w, r)
+        }
+    })
+}
+
+// applyToMainCluster creates the given manifest document in the shared KWOK cluster, namespaced
+// per virtual cluster (the virtual cluster's ID is used as the namespace) so KWOK/Rancher see it.
+func (a *ScaleAgent) applyToMainCluster(clusterID, kind string, doc []byte) error {
+    if a.mainClientset == nil {
+        return fmt.Errorf("main cluster clientset not initialized")
+    }
+    return applyDocToNamespace(a, clusterID, kind, doc)
+}
+
+// deleteFromMainCluster removes the named object from the shared KWOK cluster's per-virtual-cluster namespace.
+func (a *ScaleAgent) deleteFromMainCluster(clusterID, kind, _, name string) error {
+    if a.mainClientset == nil {
+        return fmt.Errorf("main cluster clientset not initialized")
+    }
+    return deleteDocFromNamespace(a, clusterID, kind, name)
+}
+
+// applyManifestDoc decodes a single document by kind and merges it into the ClusterInfo, replacing
+// any existing object with the same namespace/name.
+func applyManifestDoc(cluster *ClusterInfo, kind string, doc []byte) error {
+    switch kind {
+    case "Pod":
+        var p PodInfo
+        if err := yaml.Unmarshal(doc, &podDocShape{&p}); err != nil {
+            return err
+        }
+        cluster.Pods = upsertPod(cluster.Pods, p)
+    case "Deployment", "StatefulSet", "DaemonSet":
+        var d DeploymentInfo
+        if err := yaml.Unmarshal(doc, &deploymentDocShape{&d}); err != nil {
+            return err
+        }
+        d.Kind = kind
+        cluster.Deployments = upsertDeployment(cluster.Deployments, d)
+    case "Service":
+        var s ServiceInfo
+        if err := yaml.Unmarshal(doc, &serviceDocShape{&s}); err != nil {
+            return err
+        }
+        cluster.Services = upsertService(cluster.Services, s)
+    case "ConfigMap":
+        var c ConfigMapInfo
+        if err := yaml.Unmarshal(doc, &configMapDocShape{&c}); err != nil {
+            return err
+        }
+        cluster.ConfigMaps = upsertConfigMap(cluster.ConfigMaps, c)
+    case "Secret":
+        var s SecretInfo
+        if err := yaml.Unmarshal(doc, &secretDocShape{&s}); err != nil {
+            return err
+        }
+        cluster.Secrets = upsertSecret(cluster.Secrets, s)
+    case "Namespace":
+        // Namespaces aren't tracked as their own *Info slice today; nothing to merge.
+        return nil
+    default:
+        return fmt.Errorf("unsupported kind %q", kind)
+    }
+    return nil
+}
+
+// deleteManifestDoc removes the named object of the given kind from the ClusterInfo.
+func deleteManifestDoc(cluster *ClusterInfo, kind, namespace, name string) {
+    switch kind {
+    case "Pod":
+        cluster.Pods = removeByName(cluster.Pods, namespace, name, func(p PodInfo) (string, string) { return p.Namespace, p.Name })
+    case "Deployment", "StatefulSet", "DaemonSet":
+        cluster.Deployments = removeDeployment(cluster.Deployments, kind, namespace, name)
+    case "Service":
+        cluster.Services = removeByName(cluster.Services, namespace, name, func(s ServiceInfo) (string, string) { return s.Namespace, s.Name })
+    case "ConfigMap":
+        cluster.ConfigMaps = removeByName(cluster.ConfigMaps, namespace, name, func(c ConfigMapInfo) (string, string) { return c.Namespace, c.Name })
+    case "Secret":
+        cluster.Secrets = removeByName(cluster.Secrets, namespace, name, func(s SecretInfo) (string, string) { return s.Namespace, s.Name })
+    }
+}
+
+// podDocShape etc. adapt our flattened *Info structs to the nested apiVersion/kind/metadata/spec
+// shape real Kubernetes YAML uses, so yaml.Unmarshal can decode a manifest document directly.
+type podDocShape struct{ *PodInfo }
+type deploymentDocShape struct{ *DeploymentInfo }
+type serviceDocShape struct{ *ServiceInfo }
+type configMapDocShape struct{ *ConfigMapInfo }
+type secretDocShape struct{ *SecretInfo }
+
+func (d *podDocShape) UnmarshalJSON(b []byte) error {
+    var doc struct {
+        Metadata struct {
+            Name      string            `json:"name"`
+            Namespace string            `json:"namespace"`
+            Labels    map[string]string `json:"labels"`
+        } `json:"metadata"`
+        Spec struct {
+            NodeName string `json:"nodeName"`
+        } `json:"spec"`
+    }
+    if err := json.Unmarshal(b, &doc); err != nil {
+        return err
+    }
+    d.Name = doc.Metadata.Name
+    d.Namespace = defaultNamespace(doc.Metadata.Namespace)
+    d.Labels = doc.Metadata.Labels
+    d.Node = doc.Spec.NodeName
+    d.Status = "Running"
+    return nil
+}
+
+func (d *deploymentDocShape) UnmarshalJSON(b []byte) error {
+    var doc struct {
+        Metadata struct {
+            Name      string            `json:"name"`
+            Namespace string            `json:"namespace"`
+            Labels    map[string]string `json:"labels"`
+        } `json:"metadata"`
+        Spec struct {
+            Replicas int `json:"replicas"`
+        } `json:"spec"`
+    }
+    if err := json.Unmarshal(b, &doc); err != nil {
+        return err
+    }
+    d.Name = doc.Metadata.Name
+    d.Namespace = defaultNamespace(doc.Metadata.Namespace)
+    d.Labels = doc.Metadata.Labels
+    replicas := fmt.Sprintf("%d/%d", doc.Spec.Replicas, doc.Spec.Replicas)
+    d.Ready, d.UpToDate, d.Available = replicas, fmt.Sprintf("%d", doc.Spec.Replicas), fmt.Sprintf("%d", doc.Spec.Replicas)
+    return nil
+}
+
+func (d *serviceDocShape) UnmarshalJSON(b []byte) error {
+    var doc struct {
+        Metadata struct {
+            Name      string            `json:"name"`
+            Namespace string            `json:"namespace"`
+            Labels    map[string]string `json:"labels"`
+        } `json:"metadata"`
+        Spec struct {
+            Type string `json:"type"`
+        } `json:"spec"`
+    }
+    if err := json.Unmarshal(b, &doc); err != nil {
+        return err
+    }
+    d.Name = doc.Metadata.Name
+    d.Namespace = defaultNamespace(doc.Metadata.Namespace)
+    d.Labels = doc.Metadata.Labels
+    d.Type = doc.Spec.Type
+    return nil
+}
+
+func (d *configMapDocShape) UnmarshalJSON(b []byte) error {
+    var doc struct {
+        Metadata struct {
+            Name      string `json:"name"`
+            Namespace string `json:"namespace"`
+        } `json:"metadata"`
+        Data map[string]string `json:"data"`
+    }
+    if err := json.Unmarshal(b, &doc); err != nil {
+        return err
+    }
+    d.Name = doc.Metadata.Name
+    d.Namespace = defaultNamespace(doc.Metadata.Namespace)
+    for k := range doc.Data {
+        d.DataKeys = append(d.DataKeys, k)
+    }
+    d.Data = len(doc.Data)
+    return nil
+}
+
+func (d *secretDocShape) UnmarshalJSON(b []byte) error {
+    var doc struct {
+        Metadata struct {
+            Name      string `json:"name"`
+            Namespace string `json:"namespace"`
+        } `json:"metadata"`
+        Type string            `json:"type"`
+        Data map[string]string `json:"data"`
+    }
+    if err := json.Unmarshal(b, &doc); err != nil {
+        return err
+    }
+    d.Name = doc.Metadata.Name
+    d.Namespace = defaultNamespace(doc.Metadata.Namespace)
+    d.Type = doc.Type
+    for k := range doc.Data {
+        d.DataKeys = append(d.DataKeys, k)
+    }
+    d.Data = len(doc.Data)
+    return nil
+}
+
+func defaultNamespace(ns string) string {
+    if ns == "" {
+        return "default"
+    }
+    return ns
+}
+
+func upsertPod(pods []PodInfo, p PodInfo) []PodInfo {
+    for i := range pods {
+        if pods[i].Namespace == p.Namespace && pods[i].Name == p.Name {
+            pods[i] = p
+            return pods
+        }
+    }
+    return append(pods, p)
+}
+
+// normalizedDeploymentKind defaults an empty Kind to "Deployment", matching DeploymentInfo's
+// documented default so entries persisted before Kind existed still compare correctly.
+func normalizedDeploymentKind(kind string) string {
+    if kind == "" {
+        return "Deployment"
+    }
+    return kind
+}
+
+// upsertDeployment matches on (kind, namespace, name): a Deployment and a DaemonSet sharing a
+// name in the same namespace (a legal, common scale-test pattern, e.g. per-node agents) are
+// distinct objects and must not overwrite each other.
+func upsertDeployment(deps []DeploymentInfo, d DeploymentInfo) []DeploymentInfo {
+    d.Kind = normalizedDeploymentKind(d.Kind)
+    for i := range deps {
+        if normalizedDeploymentKind(deps[i].Kind) == d.Kind && deps[i].Namespace == d.Namespace && deps[i].Name == d.Name {
+            deps[i] = d
+            return deps
+        }
+    }
+    return append(deps, d)
+}
+
+// removeDeployment removes the Deployment/StatefulSet/DaemonSet matching (kind, namespace, name).
+func removeDeployment(deps []DeploymentInfo, kind, namespace, name string) []DeploymentInfo {
+    kind = normalizedDeploymentKind(kind)
+    out := deps[:0]
+    for _, d := range deps {
+        if normalizedDeploymentKind(d.Kind) == kind && d.Namespace == namespace && d.Name == name {
+            continue
+        }
+        out = append(out, d)
+    }
+    return out
+}
+
+func upsertService(svcs []ServiceInfo, s ServiceInfo) []ServiceInfo {
+    for i := range svcs {
+        if svcs[i].Namespace == s.Namespace && svcs[i].Name == s.Name {
+            svcs[i] = s
+            return svcs
+        }
+    }
+    return append(svcs, s)
+}
+
+func upsertConfigMap(cms []ConfigMapInfo, c ConfigMapInfo) []ConfigMapInfo {
+    for i := range cms {
+        if cms[i].Namespace == c.Namespace && cms[i].Name == c.Name {
+            cms[i] = c
+            return cms
+        }
+    }
+    return append(cms, c)
+}
+
+func upsertSecret(secrets []SecretInfo, s SecretInfo) []SecretInfo {
+    for i := range secrets {
+        if secrets[i].Namespace == s.Namespace && secrets[i].Name == s.Name {
+            secrets[i] = s
+            return secrets
+        }
+    }
+    return append(secrets, s)
+}
+
+func removeByName[T any](items []T, namespace, name string, key func(T) (string, string)) []T {
+    out := items[:0]
+    for _, item := range items {
+        ns, n := key(item)
+        if ns == namespace && n == name {
+            continue
+        }
+        out = append(out, item)
+    }
+    return out
+}
+
+// exportMeta is the nested metadata block the per-kind docShape.UnmarshalJSON methods expect.
+type exportMeta struct {
+    Name      string            `json:"name" yaml:"name"`
+    Namespace string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+    Labels    map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// exportDoc mirrors real Kubernetes YAML's apiVersion/kind/metadata/spec envelope so that
+// ExportCluster's output can be fed straight back into ApplyManifest: applyManifestDoc dispatches
+// purely on the top-level "kind" field, and each docShape.UnmarshalJSON reads "metadata"/"spec"
+// (or, for ConfigMap/Secret, top-level "data"/"type") from exactly this shape.
+type exportDoc struct {
+    APIVersion string      `json:"apiVersion" yaml:"apiVersion"`
+    Kind       string      `json:"kind" yaml:"kind"`
+    Metadata   exportMeta  `json:"metadata" yaml:"metadata"`
+    Spec       interface{} `json:"spec,omitempty" yaml:"spec,omitempty"`
+    Type       string      `json:"type,omitempty" yaml:"type,omitempty"`
+    Data       interface{} `json:"data,omitempty" yaml:"data,omitempty"`
+}
+
+// exportableObjects flattens a ClusterInfo into the ordered list of documents ExportCluster emits.
+func exportableObjects(cluster *ClusterInfo) []interface{} {
+    var out []interface{}
+    for _, d := range cluster.Deployments {
+        out = append(out, exportDeploymentDoc(d))
+    }
+    for _, s := range cluster.Services {
+        out = append(out, exportServiceDoc(s))
+    }
+    for _, c := range cluster.ConfigMaps {
+        out = append(out, exportConfigMapDoc(c))
+    }
+    for _, s := range cluster.Secrets {
+        out = append(out, exportSecretDoc(s))
+    }
+    for _, p := range cluster.Pods {
+        out = append(out, exportPodDoc(p))
+    }
+    return out
+}
+
+func exportPodDoc(p PodInfo) exportDoc {
+    return exportDoc{
+        APIVersion: "v1",
+        Kind:       "Pod",
+        Metadata:   exportMeta{Name: p.Name, Namespace: p.Namespace, Labels: p.Labels},
+        Spec:       map[string]interface{}{"nodeName": p.Node},
+    }
+}
+
+func exportServiceDoc(s ServiceInfo) exportDoc {
+    return exportDoc{
+        APIVersion: "v1",
+        Kind:       "Service",
+        Metadata:   exportMeta{Name: s.Name, Namespace: s.Namespace, Labels: s.Labels},
+        Spec:       map[string]interface{}{"type": s.Type},
+    }
+}
+
+func exportConfigMapDoc(c ConfigMapInfo) exportDoc {
+    // Only key names are tracked (ConfigMapInfo is a "keys only" summary), so values round-trip
+    // as empty strings; that's enough for applyManifestDoc to reconstruct the same DataKeys/Data.
+    data := make(map[string]string, len(c.DataKeys))
+    for _, k := range c.DataKeys {
+        data[k] = ""
+    }
+    return exportDoc{
+        APIVersion: "v1",
+        Kind:       "ConfigMap",
+        Metadata:   exportMeta{Name: c.Name, Namespace: c.Namespace},
+        Data:       data,
+    }
+}
+
+func exportSecretDoc(s SecretInfo) exportDoc {
+    data := make(map[string]string, len(s.DataKeys))
+    for _, k := range s.DataKeys {
+        data[k] = ""
+    }
+    return exportDoc{
+        APIVersion: "v1",
+        Kind:       "Secret",
+        Metadata:   exportMeta{Name: s.Name, Namespace: s.Namespace},
+        Type:       s.Type,
+        Data:       data,
+    }
+}
+
+func exportDeploymentDoc(d DeploymentInfo) exportDoc {
+    return exportDoc{
+        APIVersion: "apps/v1",
+        Kind:       normalizedDeploymentKind(d.Kind),
+        Metadata:   exportMeta{Name: d.Name, Namespace: d.Namespace, Labels: d.Labels},
+        Spec:       map[string]interface{}{"replicas": deploymentReadyReplicas(d.Ready)},
+    }
+}
+
+// deploymentReadyReplicas parses the "N/N" shape deploymentDocShape.UnmarshalJSON stores in Ready
+// back into a replica count, best-effort (0 if it isn't in that shape).
+func deploymentReadyReplicas(ready string) int {
+    before, _, found := strings.Cut(ready, "/")
+    if !found {
+        return 0
+    }
+    n, err := strconv.Atoi(before)
+    if err != nil {
+        return 0
+    }
+    return n
+}
+
+// splitYAMLDocs splits a multi-document YAML body on "---" separator lines.
+func splitYAMLDocs(body []byte) [][]byte {
+    parts := strings.Split(string(body), "\n---")
+    var docs [][]byte
+    for _, p := range parts {
+        if strings.TrimSpace(p) == "" {
+            continue
+        }
+        docs = append(docs, []byte(p))
+    }
+    return docs
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    _ = json.NewEncoder(w).Encode(v)
+}
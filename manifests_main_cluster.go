@@ -0,0 +1,139 @@
+package main
+
+import (
+    "context"
+    "fmt"
+
+    appsv1 "k8s.io/api/apps/v1"
+    corev1 "k8s.io/api/core/v1"
+    apierrors "k8s.io/apimachinery/pkg/api/errors"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "sigs.k8s.io/yaml"
+)
+
+// applyDocToNamespace decodes a manifest document into the real Kubernetes type for its kind and
+// creates it in the shared KWOK cluster, namespaced per virtual cluster (the virtual cluster ID
+// is used as the namespace name, created on demand).
+func applyDocToNamespace(a *ScaleAgent, clusterID, kind string, doc []byte) error {
+    ctx := context.Background()
+    ns := clusterID
+    if err := ensureMainClusterNamespace(ctx, a, ns); err != nil {
+        return err
+    }
+
+    switch kind {
+    case "Namespace":
+        return nil // already ensured above
+    case "Pod":
+        var pod corev1.Pod
+        if err := yaml.Unmarshal(doc, &pod); err != nil {
+            return err
+        }
+        pod.Namespace = ns
+        _, err := a.mainClientset.CoreV1().Pods(ns).Create(ctx, &pod, metav1.CreateOptions{})
+        return ignoreAlreadyExists(err)
+    case "Service":
+        var svc corev1.Service
+        if err := yaml.Unmarshal(doc, &svc); err != nil {
+            return err
+        }
+        svc.Namespace = ns
+        _, err := a.mainClientset.CoreV1().Services(ns).Create(ctx, &svc, metav1.CreateOptions{})
+        return ignoreAlreadyExists(err)
+    case "ConfigMap":
+        var cm corev1.ConfigMap
+        if err := yaml.Unmarshal(doc, &cm); err != nil {
+            return err
+        }
+        cm.Namespace = ns
+        _, err := a.mainClientset.CoreV1().ConfigMaps(ns).Create(ctx, &cm, metav1.CreateOptions{})
+        return ignoreAlreadyExists(err)
+    case "Secret":
+        var secret corev1.Secret
+        if err := yaml.Unmarshal(doc, &secret); err != nil {
+            return err
+        }
+        secret.Namespace = ns
+        _, err := a.mainClientset.CoreV1().Secrets(ns).Create(ctx, &secret, metav1.CreateOptions{})
+        return ignoreAlreadyExists(err)
+    case "Deployment":
+        var dep appsv1.Deployment
+        if err := yaml.Unmarshal(doc, &dep); err != nil {
+            return err
+        }
+        dep.Namespace = ns
+        _, err := a.mainClientset.AppsV1().Deployments(ns).Create(ctx, &dep, metav1.CreateOptions{})
+        return ignoreAlreadyExists(err)
+    case "StatefulSet":
+        var ss appsv1.StatefulSet
+        if err := yaml.Unmarshal(doc, &ss); err != nil {
+            return err
+        }
+        ss.Namespace = ns
+        _, err := a.mainClientset.AppsV1().StatefulSets(ns).Create(ctx, &ss, metav1.CreateOptions{})
+        return ignoreAlreadyExists(err)
+    case "DaemonSet":
+        var ds appsv1.DaemonSet
+        if err := yaml.Unmarshal(doc, &ds); err != nil {
+            return err
+        }
+        ds.Namespace = ns
+        _, err := a.mainClientset.AppsV1().DaemonSets(ns).Create(ctx, &ds, metav1.CreateOptions{})
+        return ignoreAlreadyExists(err)
+    default:
+        return fmt.Errorf("unsupported kind %q for main cluster apply", kind)
+    }
+}
+
+// deleteDocFromNamespace deletes the named object of the given kind from the virtual cluster's
+// namespace in the shared KWOK cluster.
+func deleteDocFromNamespace(a *ScaleAgent, clusterID, kind, name string) error {
+    ctx := context.Background()
+    ns := clusterID
+    var err error
+    switch kind {
+    case "Namespace":
+        return nil
+    case "Pod":
+        err = a.mainClientset.CoreV1().Pods(ns).Delete(ctx, name, metav1.DeleteOptions{})
+    case "Service":
+        err = a.mainClientset.CoreV1().Services(ns).Delete(ctx, name, metav1.DeleteOptions{})
+    case "ConfigMap":
+        err = a.mainClientset.CoreV1().ConfigMaps(ns).Delete(ctx, name, metav1.DeleteOptions{})
+    case "Secret":
+        err = a.mainClientset.CoreV1().Secrets(ns).Delete(ctx, name, metav1.DeleteOptions{})
+    case "Deployment":
+        err = a.mainClientset.AppsV1().Deployments(ns).Delete(ctx, name, metav1.DeleteOptions{})
+    case "StatefulSet":
+        err = a.mainClientset.AppsV1().StatefulSets(ns).Delete(ctx, name, metav1.DeleteOptions{})
+    case "DaemonSet":
+        err = a.mainClientset.AppsV1().DaemonSets(ns).Delete(ctx, name, metav1.DeleteOptions{})
+    default:
+        return fmt.Errorf("unsupported kind %q for main cluster delete", kind)
+    }
+    if apierrors.IsNotFound(err) {
+        return nil
+    }
+    return err
+}
+
+func ensureMainClusterNamespace(ctx context.Context, a *ScaleAgent, ns string) error {
+    _, err := a.mainClientset.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+    if err == nil {
+        return nil
+    }
+    if !apierrors.IsNotFound(err) {
+        return fmt.Errorf("get namespace %s: %w", ns, err)
+    }
+    _, err = a.mainClientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+        ObjectMeta: metav1.ObjectMeta{Name: ns},
+    }, metav1.CreateOptions{})
+    return ignoreAlreadyExists(err)
+}
+
+func ignoreAlreadyExists(err error) error {
+    if apierrors.IsAlreadyExists(err) {
+        return nil
+    }
+    return err
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+    "fmt"
+    "testing"
+
+    "sigs.k8s.io/yaml"
+)
+
+// TestApplyDeleteNamespaceDefaultAgree guards against ApplyManifest and DeleteManifest disagreeing
+// on the namespace a manifest with no `namespace:` field lands in: apply defaults it to "default"
+// before storing, so delete must default it the same way before looking it up, or the object can
+// never be removed again.
+func TestApplyDeleteNamespaceDefaultAgree(t *testing.T) {
+    cluster := &ClusterInfo{}
+    const podManifest = "kind: Pod\nmetadata:\n  name: web-0\n"
+
+    if err := applyManifestDoc(cluster, "Pod", []byte(podManifest)); err != nil {
+        t.Fatalf("applyManifestDoc: %v", err)
+    }
+    if len(cluster.Pods) != 1 {
+        t.Fatalf("expected 1 pod after apply, got %d", len(cluster.Pods))
+    }
+    if cluster.Pods[0].Namespace != "default" {
+        t.Fatalf("expected pod namespace to default to %q, got %q", "default", cluster.Pods[0].Namespace)
+    }
+
+    var meta manifestDoc
+    // Mirrors what ApplyManifest/DeleteManifest parse out of the raw document.
+    meta.Kind = "Pod"
+    meta.Metadata.Name = "web-0"
+    meta.Metadata.Namespace = "" // no namespace: field in the manifest
+
+    deleteManifestDoc(cluster, meta.Kind, defaultNamespace(meta.Metadata.Namespace), meta.Metadata.Name)
+    if len(cluster.Pods) != 0 {
+        t.Fatalf("expected pod to be deleted, but %d remain", len(cluster.Pods))
+    }
+}
+
+// TestDeploymentKindsDontCollide guards against a Deployment and a DaemonSet sharing a name in the
+// same namespace silently overwriting each other, a common scale-test pattern (e.g. per-node
+// agents deployed alongside an app of the same name).
+func TestDeploymentKindsDontCollide(t *testing.T) {
+    cluster := &ClusterInfo{}
+    manifest := "kind: %s\nmetadata:\n  name: agent\n  namespace: ops\nspec:\n  replicas: 1\n"
+
+    for _, kind := range []string{"Deployment", "DaemonSet"} {
+        doc := []byte(fmt.Sprintf(manifest, kind))
+        if err := applyManifestDoc(cluster, kind, doc); err != nil {
+            t.Fatalf("applyManifestDoc(%s): %v", kind, err)
+        }
+    }
+
+    if len(cluster.Deployments) != 2 {
+        t.Fatalf("expected a Deployment and a DaemonSet to coexist, got %d entries: %+v", len(cluster.Deployments), cluster.Deployments)
+    }
+}
+
+// TestExportRoundTripsThroughApply guards against ExportCluster emitting documents that
+// ApplyManifest can't read back: applyManifestDoc dispatches purely on a document's top-level
+// "kind" field, so exported documents must carry one.
+func TestExportRoundTripsThroughApply(t *testing.T) {
+    cluster := &ClusterInfo{
+        Pods: []PodInfo{{Name: "web-0", Namespace: "default", Node: "node-1"}},
+    }
+
+    docs := exportableObjects(cluster)
+    if len(docs) != 1 {
+        t.Fatalf("expected 1 exported document, got %d", len(docs))
+    }
+
+    raw, err := yaml.Marshal(docs[0])
+    if err != nil {
+        t.Fatalf("marshal export doc: %v", err)
+    }
+
+    var meta manifestDoc
+    if err := yaml.Unmarshal(raw, &meta); err != nil {
+        t.Fatalf("unmarshal export doc: %v", err)
+    }
+    if meta.Kind == "" {
+        t.Fatalf("exported document has no kind, ApplyManifest would silently skip it: %s", raw)
+    }
+
+    target := &ClusterInfo{}
+    if err := applyManifestDoc(target, meta.Kind, raw); err != nil {
+        t.Fatalf("applyManifestDoc(exported doc): %v", err)
+    }
+    if len(target.Pods) != 1 || target.Pods[0].Name != "web-0" || target.Pods[0].Node != "node-1" {
+        t.Fatalf("re-applied export doc did not round-trip: %+v", target.Pods)
+    }
+}
+
+// TestApplyManifestPartialFailureNotPersisted guards against a manifest body where a later
+// document fails to decode leaving earlier documents applied in memory: ApplyManifest runs as a
+// single UpdateState mutation over a deep copy, so a failure partway through must discard the
+// whole attempt rather than leaving the first document live without ever being persisted.
+func TestApplyManifestPartialFailureNotPersisted(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+
+    clusterID := "c1"
+    a := &ScaleAgent{
+        config:   &Config{},
+        clusters: map[string]*ClusterInfo{clusterID: {}},
+    }
+
+    const body = "kind: Pod\nmetadata:\n  name: web-0\n" +
+        "---\n" +
+        "kind: Deployment\nmetadata:\n  name: bad\nspec:\n  replicas: not-a-number\n"
+
+    if _, err := a.ApplyManifest(clusterID, []byte(body), false); err == nil {
+        t.Fatal("expected ApplyManifest to fail on the malformed second document")
+    }
+
+    a.stateMu.Lock()
+    cluster := a.clusters[clusterID]
+    rv := a.resourceVersion
+    a.stateMu.Unlock()
+
+    if len(cluster.Pods) != 0 {
+        t.Fatalf("expected the first document's apply to be discarded along with the failed one, got %d pods", len(cluster.Pods))
+    }
+    if rv != 0 {
+        t.Fatalf("expected resourceVersion to stay at 0 since the update never committed, got %d", rv)
+    }
+}
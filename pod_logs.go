@@ -0,0 +1,306 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "hash/fnv"
+    "math/rand"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// logGenerator produces one synthetic log line per call. Implementations must be safe to call
+// repeatedly from a single goroutine driving a single stream; they are not shared across streams.
+type logGenerator interface {
+    // Next returns the next log line body (without trailing newline or timestamp prefix).
+    Next(seq int) string
+}
+
+// logStreamOptions captures the query parameters accepted by both the agent's own log endpoint
+// and the intercepted /api/v1/namespaces/{ns}/pods/{name}/log path, mirroring kubectl logs.
+type logStreamOptions struct {
+    Follow       bool
+    Tail         int // 0 means the default backlog streamPodLog emits (100 lines)
+    SinceSeconds int
+    Timestamps   bool
+    Previous     bool
+    Container    string
+}
+
+func parseLogStreamOptions(r *http.Request) logStreamOptions {
+    q := r.URL.Query()
+    opts := logStreamOptions{
+        Follow:     q.Get("follow") == "1" || q.Get("follow") == "true",
+        Timestamps: q.Get("timestamps") == "1" || q.Get("timestamps") == "true",
+        Previous:   q.Get("previous") == "1" || q.Get("previous") == "true",
+        Container:  q.Get("container"),
+    }
+    if v, err := strconv.Atoi(q.Get("tail")); err == nil {
+        opts.Tail = v
+    }
+    if v, err := strconv.Atoi(q.Get("sinceSeconds")); err == nil {
+        opts.SinceSeconds = v
+    }
+    return opts
+}
+
+// handlePodLogs implements GET /clusters/{id}/pods/{ns}/{name}/log, the agent's first-class log
+// endpoint for KWOK-backed pods, which have no real container runtime and so return nothing from
+// `kubectl logs`. The response streams deterministic synthetic lines seeded from the pod name and
+// container name (see podLogSeed), so repeated requests for the same pod/container reproduce the
+// same log.
+func (a *ScaleAgent) handlePodLogs(w http.ResponseWriter, r *http.Request, clusterID, namespace, podName string) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    a.connMutex.RLock()
+    cluster, ok := a.clusters[clusterID]
+    a.connMutex.RUnlock()
+    if !ok {
+        http.Error(w, fmt.Sprintf("unknown cluster %q", clusterID), http.StatusNotFound)
+        return
+    }
+    pod, ok := findPod(cluster, namespace, podName)
+    if !ok {
+        http.Error(w, fmt.Sprintf("unknown pod %s/%s", namespace, podName), http.StatusNotFound)
+        return
+    }
+
+    opts := parseLogStreamOptions(r)
+    if opts.Previous && pod.Restarts == 0 {
+        http.Error(w, fmt.Sprintf("previous terminated container not found for %s/%s (pod has not restarted)", namespace, podName), http.StatusBadRequest)
+        return
+    }
+
+    seed := podLogSeed(pod.Name, opts.Container, opts.Previous)
+    gen, err := newLogGenerator(seed, r.URL.Query().Get("replayFile"), a.config.ReplayLogDir)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    streamPodLog(r.Context(), w, gen, opts)
+}
+
+// kubeAPIPodLogPathPrefix/Suffix bound the {ns}/{name} segment of the real Kubernetes API path
+// the mock/proxy server intercepts: /api/v1/namespaces/{ns}/pods/{name}/log.
+const (
+    kubeAPIPodLogPathPrefix = "/api/v1/namespaces/"
+    kubeAPIPodLogPathInfix  = "/pods/"
+    kubeAPIPodLogPathSuffix = "/log"
+)
+
+// parseKubeAPIPodLogPath extracts namespace/name from a path matching
+// /api/v1/namespaces/{ns}/pods/{name}/log, for the mock/proxy server's kubectl-logs interception.
+func parseKubeAPIPodLogPath(path string) (namespace, name string, ok bool) {
+    if !strings.HasPrefix(path, kubeAPIPodLogPathPrefix) || !strings.HasSuffix(path, kubeAPIPodLogPathSuffix) {
+        return "", "", false
+    }
+    middle := strings.TrimSuffix(strings.TrimPrefix(path, kubeAPIPodLogPathPrefix), kubeAPIPodLogPathSuffix)
+    nsAndName := strings.SplitN(middle, kubeAPIPodLogPathInfix, 2)
+    if len(nsAndName) != 2 || nsAndName[0] == "" || nsAndName[1] == "" {
+        return "", "", false
+    }
+    return nsAndName[0], nsAndName[1], true
+}
+
+// handleKubeAPIPodLog serves a pod log request shaped like the real Kubernetes API
+// (/api/v1/namespaces/{ns}/pods/{name}/log) so it can be wired into the mock/proxy server's
+// request router alongside its other intercepted endpoints.
+func (a *ScaleAgent) handleKubeAPIPodLog(w http.ResponseWriter, r *http.Request, clusterID string) {
+    namespace, name, ok := parseKubeAPIPodLogPath(r.URL.Path)
+    if !ok {
+        http.NotFound(w, r)
+        return
+    }
+    a.handlePodLogs(w, r, clusterID, namespace, name)
+}
+
+// findPod looks up a pod by namespace/name within a ClusterInfo.
+func findPod(cluster *ClusterInfo, namespace, name string) (PodInfo, bool) {
+    for _, p := range cluster.Pods {
+        if p.Namespace == namespace && p.Name == name {
+            return p, true
+        }
+    }
+    return PodInfo{}, false
+}
+
+// podLogSeed derives a stable seed from the pod name and container name (standing in for pod UID,
+// which this package doesn't track) so the same pod/container always produces the same log.
+// previous is folded in so ?previous=true deterministically yields different (but equally stable)
+// content from the current container's log, mirroring distinct real log streams.
+func podLogSeed(podName, container string, previous bool) int64 {
+    h := fnv.New64a()
+    _, _ = h.Write([]byte(podName + "/" + container))
+    if previous {
+        _, _ = h.Write([]byte("/previous"))
+    }
+    return int64(h.Sum64())
+}
+
+// streamPodLog writes generated log lines to w as they're produced, flushing after each line
+// (whenever w is an http.Flusher) so a follow client gets them as a chunked transfer rather than
+// buffered. It stops
+// when the request context is cancelled (client disconnect) or, without Follow, after emitting the
+// requested number of lines.
+func streamPodLog(ctx interface{ Done() <-chan struct{} }, w http.ResponseWriter, gen logGenerator, opts logStreamOptions) {
+    flusher, _ := w.(http.Flusher)
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    w.WriteHeader(http.StatusOK)
+
+    lineCount := opts.Tail
+    if lineCount <= 0 {
+        lineCount = 100 // a bounded default backlog for requests with no explicit --tail
+    }
+    if opts.SinceSeconds > 0 {
+        // Synthetic lines have no real history to filter, so approximate "since" by assuming a
+        // steady-state rate of one line per second and capping the backlog to that many lines.
+        if opts.SinceSeconds < lineCount {
+            lineCount = opts.SinceSeconds
+        }
+    }
+
+    emit := func(seq int) bool {
+        line := gen.Next(seq)
+        if opts.Timestamps {
+            line = time.Now().UTC().Format(time.RFC3339Nano) + " " + line
+        }
+        if _, err := fmt.Fprintln(w, line); err != nil {
+            return false
+        }
+        if flusher != nil {
+            flusher.Flush()
+        }
+        return true
+    }
+
+    seq := 0
+    for ; seq < lineCount; seq++ {
+        if !emit(seq) {
+            return
+        }
+    }
+    if !opts.Follow {
+        return
+    }
+
+    ticker := time.NewTicker(200 * time.Millisecond)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if !emit(seq) {
+                return
+            }
+            seq++
+        }
+    }
+}
+
+// newLogGenerator builds the default mixed-level generator, or a "replay" generator reading lines
+// from replayFile when one is given. replayFile is a request-supplied name, never a path: it is
+// resolved against replayDir and rejected if it would escape that directory, so a caller can't use
+// ?replayFile= to read arbitrary files off the host running the agent.
+func newLogGenerator(seed int64, replayFile, replayDir string) (logGenerator, error) {
+    if replayFile == "" {
+        return newDefaultLogGenerator(seed), nil
+    }
+    if replayDir == "" {
+        return nil, fmt.Errorf("replay log files are disabled (Config.ReplayLogDir is not set)")
+    }
+    path, err := resolveReplayPath(replayDir, replayFile)
+    if err != nil {
+        return nil, err
+    }
+    return newReplayLogGenerator(path)
+}
+
+// resolveReplayPath joins requested onto baseDir and confirms the result is still inside baseDir,
+// rejecting absolute paths and "../" escapes.
+func resolveReplayPath(baseDir, requested string) (string, error) {
+    if filepath.IsAbs(requested) {
+        return "", fmt.Errorf("replayFile must be a relative path")
+    }
+    absBase, err := filepath.Abs(baseDir)
+    if err != nil {
+        return "", fmt.Errorf("resolve replay log directory: %w", err)
+    }
+    candidate := filepath.Join(absBase, requested)
+    if candidate != absBase && !strings.HasPrefix(candidate, absBase+string(filepath.Separator)) {
+        return "", fmt.Errorf("replayFile %q escapes replay log directory", requested)
+    }
+    return candidate, nil
+}
+
+// defaultLogGenerator mixes INFO/WARN/ERROR lines at the warn/error rates set by
+// newDefaultLogGenerator (10% WARN, 2% ERROR); no Config field or query parameter changes them.
+// Output is deterministic for a given seed so the same pod/container always "replays" the same
+// synthetic log.
+type defaultLogGenerator struct {
+    rng       *rand.Rand
+    warnRate  float64 // probability a given line is WARN instead of INFO
+    errorRate float64 // probability a given line is ERROR instead of INFO
+}
+
+func newDefaultLogGenerator(seed int64) *defaultLogGenerator {
+    return &defaultLogGenerator{
+        rng:       rand.New(rand.NewSource(seed)),
+        warnRate:  0.1,
+        errorRate: 0.02,
+    }
+}
+
+func (g *defaultLogGenerator) Next(seq int) string {
+    level := "INFO"
+    switch roll := g.rng.Float64(); {
+    case roll < g.errorRate:
+        level = "ERROR"
+    case roll < g.errorRate+g.warnRate:
+        level = "WARN"
+    }
+    return fmt.Sprintf("[%s] synthetic log line %d", level, seq)
+}
+
+// replayLogGenerator cycles through the lines of a user-supplied file, so a scale test can measure
+// log-fanout behavior against realistic, non-random log content.
+type replayLogGenerator struct {
+    lines []string
+}
+
+func newReplayLogGenerator(path string) (*replayLogGenerator, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open replay log file: %w", err)
+    }
+    defer f.Close()
+
+    var lines []string
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        line := scanner.Text()
+        if strings.TrimSpace(line) == "" {
+            continue
+        }
+        lines = append(lines, line)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("failed to read replay log file: %w", err)
+    }
+    if len(lines) == 0 {
+        return nil, fmt.Errorf("replay log file %s has no content", path)
+    }
+    return &replayLogGenerator{lines: lines}, nil
+}
+
+func (g *replayLogGenerator) Next(seq int) string {
+    return g.lines[seq%len(g.lines)]
+}
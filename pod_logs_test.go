@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+// TestResolveReplayPathRejectsEscape guards the ?replayFile= handler against reading arbitrary
+// files off the host: anything that isn't contained within the configured replay log directory
+// must be rejected.
+func TestResolveReplayPathRejectsEscape(t *testing.T) {
+    baseDir := t.TempDir()
+
+    cases := []struct {
+        name      string
+        requested string
+        wantErr   bool
+    }{
+        {"plain file", "app.log", false},
+        {"nested file", "sub/app.log", false},
+        {"absolute path", "/etc/passwd", true},
+        {"parent traversal", "../etc/passwd", true},
+        {"deep parent traversal", "sub/../../etc/passwd", true},
+    }
+
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            _, err := resolveReplayPath(baseDir, tc.requested)
+            if tc.wantErr && err == nil {
+                t.Fatalf("resolveReplayPath(%q) = nil error, want error", tc.requested)
+            }
+            if !tc.wantErr && err != nil {
+                t.Fatalf("resolveReplayPath(%q) = %v, want no error", tc.requested, err)
+            }
+        })
+    }
+}
+
+// TestNewLogGeneratorDisabledWithoutReplayDir ensures a bare replayFile query param can't be used
+// to read files when no replay directory has been configured.
+func TestNewLogGeneratorDisabledWithoutReplayDir(t *testing.T) {
+    if _, err := newLogGenerator(1, "app.log", ""); err == nil {
+        t.Fatal("expected error when ReplayLogDir is unset, got nil")
+    }
+}
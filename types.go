@@ -10,6 +10,10 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/workqueue"
 )
 
 // Config holds runtime configuration for the agent.
@@ -24,10 +28,24 @@ type Config struct {
     MainAPIPort     int    `json:"MainAPIPort"`   // secure or insecure port exposed by KWOK apiserver
     ProxyBasePort   int    `json:"ProxyBasePort"` // starting port for per-virtual-cluster proxies
 
+    // client-go QPS/Burst for the shared main-cluster clientset/informers; default to 50/100 if unset
+    MainQPS   float32 `json:"MainQPS"`
+    MainBurst int     `json:"MainBurst"`
+
     // Diagnostics/profiling
     PprofEnable        bool `json:"PprofEnable"`
     PprofPort          int  `json:"PprofPort"`
     MemLogIntervalSec  int  `json:"MemLogIntervalSec"` // if >0, periodically logs mem stats
+
+    // MetricsPort serves /healthz, /readyz and /metrics. If zero, these are mounted on PprofPort instead.
+    MetricsPort        int `json:"MetricsPort"`
+    // AnnotatorStallSec controls how long the pod usage annotator can go without a pass before /healthz fails.
+    AnnotatorStallSec  int `json:"AnnotatorStallSec"`
+
+    // ReplayLogDir, if set, is the only directory pod log "replay" generators may read from; the
+    // per-request ?replayFile= value is resolved relative to it and rejected if it escapes. Empty
+    // disables the replay generator entirely.
+    ReplayLogDir       string `json:"ReplayLogDir"`
 }
 
 // ScaleAgent is the main application state container.
@@ -40,6 +58,17 @@ type ScaleAgent struct {
     clusters    map[string]*ClusterInfo
     kwokManager *KWOKClusterManager
 
+    // Main cluster client-go clientset + informers, owned by Start/cluster_controller.go;
+    // shared with other subsystems (proxy, mock server) instead of each building their own client.
+    mainClientset       kubernetes.Interface
+    mainInformerFactory informers.SharedInformerFactory
+    podCounts           map[string]int // nodeName -> non-terminal pod count, kept by informer handlers
+    podCountsMu         sync.RWMutex
+    nodeQueue           workqueue.RateLimitingInterface
+
+    // diag tracks readiness/liveness signals surfaced on /healthz and /readyz.
+    diag *diagnosticsState
+
     // HTTP server
     httpServer *http.Server
     httpServerOnce sync.Once
@@ -69,6 +98,11 @@ type ScaleAgent struct {
     connMutex sync.RWMutex
     caMutex   sync.RWMutex
 
+    // State persistence: guards the clusters/proxyPorts CAS-swap done by UpdateState, and the
+    // resourceVersion bumped on every successful one. See UpdateState in this file.
+    stateMu         sync.Mutex
+    resourceVersion uint64
+
     // Backoff / attempt tracking
     lastConnectAttempt   map[string]time.Time
     lastCAConnectAttempt map[string]time.Time
@@ -162,8 +196,11 @@ type ConfigMapInfo struct {
     Age       string   `json:"age,omitempty" yaml:"age,omitempty"`
 }
 
-// DeploymentInfo summarizes a deployment for reporting.
+// DeploymentInfo summarizes a deployment for reporting. Kind distinguishes Deployment from
+// StatefulSet/DaemonSet, all of which are tracked in this one slice; it defaults to "Deployment"
+// when empty so pre-existing persisted state without the field still behaves as before.
 type DeploymentInfo struct {
+    Kind       string            `json:"kind,omitempty" yaml:"kind,omitempty"`
     Name       string            `json:"name" yaml:"name"`
     Namespace  string            `json:"namespace" yaml:"namespace"`
     Ready      string            `json:"ready,omitempty" yaml:"ready,omitempty"`
@@ -173,6 +210,17 @@ type DeploymentInfo struct {
     Labels     map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 }
 
+// State is the full persisted payload. ResourceVersion is a monotonically increasing counter
+// bumped on every successful UpdateState, etcd3-style, so concurrent mutators (proxy startup
+// writing ProxyPorts, tunnel handlers mutating cluster status) can detect and retry on conflict
+// instead of racing on a shared map under implicit locking.
+type State struct {
+    Clusters        map[string]*ClusterInfo `json:"clusters"`
+    ProxyPorts      map[string]int          `json:"proxyPorts,omitempty"`
+    Version         string                  `json:"version"`
+    ResourceVersion uint64                  `json:"resourceVersion"`
+}
+
 // stateFile returns the path to the persisted state file under the user's home.
 func stateFile() (string, error) {
     home, err := os.UserHomeDir()
@@ -186,25 +234,86 @@ func stateFile() (string, error) {
     return filepath.Join(dir, "state.json"), nil
 }
 
-// SaveState persists minimal agent state to disk (best-effort, non-fatal).
+// snapshotStateLocked returns a deep copy of the agent's current state, suitable for handing to
+// an UpdateState mutator to edit without racing other readers/writers. Caller must hold stateMu.
+func (a *ScaleAgent) snapshotStateLocked() (*State, error) {
+    cur := &State{
+        Clusters:        a.clusters,
+        ProxyPorts:      a.proxyPorts,
+        Version:         version,
+        ResourceVersion: a.resourceVersion,
+    }
+    data, err := json.Marshal(cur)
+    if err != nil {
+        return nil, fmt.Errorf("failed to snapshot state: %w", err)
+    }
+    var dup State
+    if err := json.Unmarshal(data, &dup); err != nil {
+        return nil, fmt.Errorf("failed to deep-copy state: %w", err)
+    }
+    return &dup, nil
+}
+
+// UpdateState reads the current state snapshot, runs mutate on a deep copy of it, and CAS-swaps
+// the result into the agent under stateMu, retrying with a bounded loop if another UpdateState
+// call committed in between. This is the only path that should mutate a.clusters/a.proxyPorts;
+// it both avoids races on the shared maps and persists each successful update to disk.
+func (a *ScaleAgent) UpdateState(mutate func(cur *State) (*State, error)) error {
+    const maxAttempts = 10
+    for attempt := 0; attempt < maxAttempts; attempt++ {
+        a.stateMu.Lock()
+        cur, err := a.snapshotStateLocked()
+        a.stateMu.Unlock()
+        if err != nil {
+            return err
+        }
+
+        next, err := mutate(cur)
+        if err != nil {
+            return err
+        }
+        if next == nil {
+            return fmt.Errorf("UpdateState: mutate func returned nil state")
+        }
+
+        a.stateMu.Lock()
+        if a.resourceVersion != cur.ResourceVersion {
+            a.stateMu.Unlock()
+            continue // another UpdateState committed while mutate ran; retry against fresh state
+        }
+        next.ResourceVersion = cur.ResourceVersion + 1
+        next.Version = version
+        a.clusters = next.Clusters
+        a.proxyPorts = next.ProxyPorts
+        a.resourceVersion = next.ResourceVersion
+        // Persist while still holding stateMu so concurrent commits reach disk in
+        // ResourceVersion order (and the .bak file is never newer than the primary).
+        err = a.persistState(next)
+        a.stateMu.Unlock()
+        return err
+    }
+    return fmt.Errorf("UpdateState: too many conflicting concurrent updates")
+}
+
+// SaveState persists the agent's current state to disk as a no-op mutation through UpdateState,
+// bumping ResourceVersion. Kept for callers that just want to flush, without editing anything.
 func (a *ScaleAgent) SaveState() error {
     if a == nil {
         return nil
     }
+    return a.UpdateState(func(cur *State) (*State, error) { return cur, nil })
+}
+
+// persistState writes payload to disk atomically (temp file + fsync + rename + dir fsync),
+// backing up the previous primary file first so LoadState can fall back to it.
+func (a *ScaleAgent) persistState(payload *State) error {
+    start := time.Now()
+    defer func() { metricStateSaveLatency.Observe(time.Since(start).Seconds()) }()
+
     path, err := stateFile()
     if err != nil {
         return err
     }
-    // Only persist clusters; KWOK runtime state is reconstructed on demand.
-    payload := struct {
-        Clusters   map[string]*ClusterInfo `json:"clusters"`
-        ProxyPorts map[string]int          `json:"proxyPorts,omitempty"`
-        Version    string                  `json:"version"`
-    }{
-        Clusters:   a.clusters,
-        ProxyPorts: a.proxyPorts,
-        Version:    version,
-    }
     data, err := json.MarshalIndent(payload, "", "  ")
     if err != nil {
         return fmt.Errorf("failed to marshal state: %w", err)
@@ -249,7 +358,7 @@ func (a *ScaleAgent) SaveState() error {
     return nil
 }
 
-// LoadState attempts to restore minimal agent state from disk.
+// LoadState attempts to restore agent state from disk.
 func (a *ScaleAgent) LoadState() error {
     if a == nil {
         return nil
@@ -271,16 +380,14 @@ func (a *ScaleAgent) LoadState() error {
     }
     // If file is empty or corrupt, try backup
     use := b
-    var payload struct {
-        Clusters   map[string]*ClusterInfo `json:"clusters"`
-        ProxyPorts map[string]int          `json:"proxyPorts,omitempty"`
-        Version    string                  `json:"version"`
-    }
+    usedBackup := false
+    var payload State
     if len(use) == 0 || json.Unmarshal(use, &payload) != nil {
         if bb, berr := os.ReadFile(path+".bak"); berr == nil && len(bb) > 0 {
             if uerr := json.Unmarshal(bb, &payload); uerr == nil {
                 // Restore from backup
                 use = bb
+                usedBackup = true
             } else {
                 return fmt.Errorf("failed to unmarshal state and backup: %v / %v", json.Unmarshal(use, &payload), uerr)
             }
@@ -288,11 +395,23 @@ func (a *ScaleAgent) LoadState() error {
             return fmt.Errorf("failed to unmarshal state: %w", json.Unmarshal(use, &payload))
         }
     }
+    // The backup is always written right before a newer primary replaces it, so it should never
+    // carry a strictly newer ResourceVersion than whatever we just loaded. If it does, the primary
+    // (or the backup) has been tampered with or corrupted in a way json.Unmarshal didn't catch.
+    if !usedBackup {
+        if bb, berr := os.ReadFile(path + ".bak"); berr == nil && len(bb) > 0 {
+            var backupPayload State
+            if uerr := json.Unmarshal(bb, &backupPayload); uerr == nil && backupPayload.ResourceVersion > payload.ResourceVersion {
+                return fmt.Errorf("state backup resourceVersion %d is newer than primary resourceVersion %d, refusing to load", backupPayload.ResourceVersion, payload.ResourceVersion)
+            }
+        }
+    }
     if payload.Clusters == nil {
         payload.Clusters = make(map[string]*ClusterInfo)
     }
     a.clusters = payload.Clusters
     if payload.ProxyPorts == nil { payload.ProxyPorts = make(map[string]int) }
     a.proxyPorts = payload.ProxyPorts
+    a.resourceVersion = payload.ResourceVersion
     return nil
 }
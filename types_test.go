@@ -0,0 +1,101 @@
+package main
+
+import (
+    "sync"
+    "testing"
+)
+
+// TestUpdateStateRetriesOnConflict guards the CAS/retry loop that's the whole point of
+// UpdateState: a mutator whose snapshot goes stale mid-flight (because another UpdateState call
+// committed first) must retry against the fresh state rather than either clobbering the other
+// writer's change or losing its own.
+func TestUpdateStateRetriesOnConflict(t *testing.T) {
+    t.Setenv("HOME", t.TempDir()) // isolate persistState's state.json from the real home dir
+
+    a := &ScaleAgent{clusters: make(map[string]*ClusterInfo), proxyPorts: make(map[string]int)}
+
+    started := make(chan struct{})
+    proceed := make(chan struct{})
+
+    var wg sync.WaitGroup
+    wg.Add(1)
+    attempts := 0
+    go func() {
+        defer wg.Done()
+        err := a.UpdateState(func(cur *State) (*State, error) {
+            attempts++
+            if attempts == 1 {
+                close(started)
+                <-proceed // stall so the other writer below commits first, forcing a conflict
+            }
+            if cur.ProxyPorts == nil {
+                cur.ProxyPorts = make(map[string]int)
+            }
+            cur.ProxyPorts["slow"]++
+            return cur, nil
+        })
+        if err != nil {
+            t.Errorf("slow UpdateState: %v", err)
+        }
+    }()
+
+    <-started
+    if err := a.UpdateState(func(cur *State) (*State, error) {
+        if cur.ProxyPorts == nil {
+            cur.ProxyPorts = make(map[string]int)
+        }
+        cur.ProxyPorts["fast"]++
+        return cur, nil
+    }); err != nil {
+        t.Fatalf("fast UpdateState: %v", err)
+    }
+    close(proceed)
+    wg.Wait()
+
+    if attempts < 2 {
+        t.Fatalf("expected the slow writer to retry at least once after losing the race, attempted %d time(s)", attempts)
+    }
+
+    a.stateMu.Lock()
+    defer a.stateMu.Unlock()
+    if a.proxyPorts["fast"] != 1 || a.proxyPorts["slow"] != 1 {
+        t.Fatalf("expected both writers' increments to survive the conflict, got %+v", a.proxyPorts)
+    }
+    if a.resourceVersion != 2 {
+        t.Fatalf("expected resourceVersion 2 after two committed updates, got %d", a.resourceVersion)
+    }
+}
+
+// TestUpdateStateConcurrentWriters runs a batch of UpdateState calls concurrently and checks that
+// every one of them lands: none of their increments is lost to a racing CAS swap.
+func TestUpdateStateConcurrentWriters(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+
+    a := &ScaleAgent{clusters: make(map[string]*ClusterInfo), proxyPorts: make(map[string]int)}
+
+    const writers = 6
+    var wg sync.WaitGroup
+    wg.Add(writers)
+    for i := 0; i < writers; i++ {
+        go func() {
+            defer wg.Done()
+            err := a.UpdateState(func(cur *State) (*State, error) {
+                if cur.ProxyPorts == nil {
+                    cur.ProxyPorts = make(map[string]int)
+                }
+                cur.ProxyPorts["n"]++
+                return cur, nil
+            })
+            if err != nil {
+                t.Errorf("UpdateState: %v", err)
+            }
+        }()
+    }
+    wg.Wait()
+
+    a.stateMu.Lock()
+    defer a.stateMu.Unlock()
+    if a.proxyPorts["n"] != writers {
+        t.Fatalf("expected %d increments to survive concurrent UpdateState calls, got %d", writers, a.proxyPorts["n"])
+    }
+}